@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ClusterState is the small bit of state a server persists to disk so it
+// can rejoin the cluster after a restart without relying on the
+// hardcoded localhost seed: the encryption key it was using, and the
+// last set of peers it could see.
+type ClusterState struct {
+	Key   []byte   `json:"key"`
+	Peers []string `json:"peers"`
+}
+
+func clusterStatePath(port int) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gossip-example-%d.json", port))
+}
+
+// loadClusterState reads the state persisted by a previous run of this
+// server, if any. A missing file is not an error; the caller should fall
+// back to generating a new key and using the default seed.
+func loadClusterState(port int) (*ClusterState, error) {
+	buf, err := os.ReadFile(clusterStatePath(port))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cs ClusterState
+	if err := json.Unmarshal(buf, &cs); err != nil {
+		return nil, err
+	}
+	return &cs, nil
+}
+
+func saveClusterState(port int, cs ClusterState) error {
+	buf, err := json.Marshal(cs)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(clusterStatePath(port), buf, 0600)
+}
+
+// persistClusterState snapshots the current key and member list to disk.
+func (s *server) persistClusterState() {
+	peers := make([]string, 0, len(s.list.Members()))
+	for _, m := range s.list.Members() {
+		peers = append(peers, fmt.Sprintf("%s:%d", m.Addr, m.Port))
+	}
+	cs := ClusterState{Key: s.keyring.GetPrimaryKey(), Peers: peers}
+	if err := saveClusterState(s.port, cs); err != nil {
+		s.logger.Printf("persist cluster state: %v", err)
+	}
+}
+
+// persistPeriodically keeps the on-disk cluster state fresh so a crashed
+// server can rejoin from its last known peers instead of localhost.
+func (s *server) persistPeriodically() {
+	for range time.Tick(30 * time.Second) {
+		s.persistClusterState()
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// defaultSeedDNSTimeout bounds how long seedAddrs will retry resolving
+// SeedDNSName before giving up and falling back to the localhost seed.
+const defaultSeedDNSTimeout = 30 * time.Second
+
+// TransportProfile selects which of memberlist's built-in tuning
+// profiles a server starts from before any other overrides are applied.
+type TransportProfile int
+
+const (
+	// ProfileLocal is tuned for running many nodes on one loopback
+	// interface, which is what this demo does by default.
+	ProfileLocal TransportProfile = iota
+	// ProfileLAN is tuned for a typical single-datacenter network.
+	ProfileLAN
+	// ProfileWAN is tuned for higher latency, lower bandwidth links
+	// between nodes spread across different networks.
+	ProfileWAN
+)
+
+func (p TransportProfile) memberlistConfig() *memberlist.Config {
+	switch p {
+	case ProfileLAN:
+		return memberlist.DefaultLANConfig()
+	case ProfileWAN:
+		return memberlist.DefaultWANConfig()
+	default:
+		return memberlist.DefaultLocalConfig()
+	}
+}
+
+// Config customizes how a server binds and discovers peers, on top of
+// whichever memberlist tuning Profile selects. The zero value
+// reproduces the original hardcoded behavior: a local-tuned node
+// binding every interface and seeding off localhost.
+type Config struct {
+	// Profile picks the base memberlist.Config to tune from.
+	Profile TransportProfile
+	// BindAddr overrides the interface memberlist binds to. Empty uses
+	// the profile's default (all interfaces).
+	BindAddr string
+	// AdvertisePort overrides the port advertised to peers. Zero
+	// advertises the same port the server binds to.
+	AdvertisePort int
+	// Transport, if set, replaces memberlist's default net.Transport
+	// (e.g. with a TLS-wrapped or in-memory transport for tests).
+	Transport memberlist.Transport
+	// Seeds is a static list of "host:port" peers to join through.
+	// Takes precedence over SeedDNSName.
+	Seeds []string
+	// SeedDNSName, if set, is resolved (SRV first, falling back to
+	// A/AAAA) to discover seed peers when no persisted peers or static
+	// Seeds are available.
+	SeedDNSName string
+	// SeedDNSTimeout bounds how long to retry resolving SeedDNSName
+	// before falling back to the localhost seed. Zero uses
+	// defaultSeedDNSTimeout.
+	SeedDNSTimeout time.Duration
+}
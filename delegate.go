@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// nodeMeta is the metadata every node advertises about itself, so peers
+// can be introspected via list.Members() without waiting for a
+// broadcast to arrive.
+type nodeMeta struct {
+	Color string `json:"color"`
+}
+
+// gossipDelegate implements memberlist.Delegate on behalf of a server.
+// Splitting it out keeps the always-on gossip plumbing (broadcasts,
+// push/pull) separate from the Conflict/Alive hooks below, which are
+// easier to reason about as their own small types, and from membership
+// events, which arrive on a channel drained by eventLoop (events.go).
+type gossipDelegate struct {
+	s *server
+}
+
+// NodeMeta is used to retrieve meta-data about the current node
+// when broadcasting an alive message. It's length is limited to
+// the given byte size. This metadata is available in the Node structure.
+func (d *gossipDelegate) NodeMeta(limit int) []byte {
+	e, _ := d.s.store.get(colorKey)
+	buf, err := json.Marshal(nodeMeta{Color: e.Value})
+	if err != nil || len(buf) > limit {
+		return nil
+	}
+	return buf
+}
+
+// NotifyMsg is called when a user-data message is received.
+// Care should be taken that this method does not block, since doing
+// so would block the entire UDP packet receive loop. Additionally, the byte
+// slice may be modified after the call returns, so it should be copied if needed
+func (d *gossipDelegate) NotifyMsg(buf []byte) {
+	d.s.logger.Printf("NotifyMsg(%q)", buf)
+	if len(buf) == 0 {
+		return
+	}
+
+	kind, payload := messageType(buf[0]), buf[1:]
+	switch kind {
+	case stateMsg:
+		var change StateChange
+		if err := json.Unmarshal(payload, &change); err != nil {
+			d.s.logger.Fatal(err)
+		}
+		d.s.processStateChange(change.Key, change.Entry)
+	default:
+		d.s.logger.Printf("NotifyMsg: unknown message type %d", kind)
+	}
+}
+
+// GetBroadcasts is called when user data messages can be broadcast.
+// It can return a list of buffers to send. Each buffer should assume an
+// overhead as provided with a limit on the total byte size allowed.
+// The total byte size of the resulting data to send must not exceed
+// the limit. Care should be taken that this method does not block,
+// since doing so would block the entire UDP packet receive loop.
+func (d *gossipDelegate) GetBroadcasts(overhead, limit int) [][]byte {
+	return d.s.queue.GetBroadcasts(overhead, limit)
+}
+
+// LocalState is used for a TCP Push/Pull. This is sent to
+// the remote side in addition to the membership information. The full
+// key/value map is sent every time, making each push/pull a complete
+// anti-entropy sweep rather than a partial sync. See MergeRemoteState
+// as well. The `join` boolean indicates this is for a join instead of a
+// push/pull.
+func (d *gossipDelegate) LocalState(join bool) []byte {
+	d.s.logger.Printf("LocalState(%v)", join)
+	buf, _ := json.Marshal(d.s.store.snapshot())
+	return buf
+}
+
+// MergeRemoteState is invoked after a TCP Push/Pull. This is the full
+// key/value map from the remote side, merged entry-by-entry against our
+// own rather than replacing it outright, so neither side can lose a
+// key the other doesn't happen to know about. The 'join' boolean
+// indicates this is for a join instead of a push/pull.
+func (d *gossipDelegate) MergeRemoteState(buf []byte, join bool) {
+	d.s.logger.Printf("MergeRemoteState(%q, %v)", buf, join)
+	var remote map[string]entry
+	if err := json.Unmarshal(buf, &remote); err != nil {
+		d.s.logger.Fatal(err)
+	}
+
+	changed := d.s.store.merge(remote)
+	for _, key := range changed {
+		if e, ok := d.s.store.get(key); ok {
+			d.s.onStoreChange(key, e)
+		}
+	}
+	if len(changed) > 0 {
+		d.s.updates <- report{kind: reportValueChange}
+	}
+}
+
+// conflictDelegate implements memberlist.ConflictDelegate, logging when
+// two nodes claim the same name instead of letting one silently win.
+type conflictDelegate struct {
+	s *server
+}
+
+func (d *conflictDelegate) NotifyConflict(existing, other *memberlist.Node) {
+	d.s.logger.Printf("name conflict: %q claimed by both %s and %s", existing.Name, existing.Address(), other.Address())
+}
+
+// aliveDelegate implements memberlist.AliveDelegate. It accepts every
+// peer, but gives us a hook to inspect nodes as they're discovered.
+type aliveDelegate struct {
+	s *server
+}
+
+func (d *aliveDelegate) NotifyAlive(peer *memberlist.Node) error {
+	d.s.logger.Printf("alive: %s meta=%q", peer.Address(), peer.Meta)
+	return nil
+}
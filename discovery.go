@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// resolveSeeds turns a DNS name into "host:port" seed addresses, trying
+// an SRV lookup first (so the record can publish its own port) and
+// falling back to a plain A/AAAA lookup against defaultPort. It retries
+// with backoff since the demo may start before DNS is ready, e.g. a
+// container's service record hasn't propagated yet, until ctx is done
+// (the caller is expected to pass a context with a deadline so a name
+// that never resolves can't block the caller forever).
+func resolveSeeds(ctx context.Context, name string, defaultPort int) ([]string, error) {
+	backoff := time.Second
+	for {
+		if _, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name); err == nil && len(srvs) > 0 {
+			seeds := make([]string, len(srvs))
+			for i, srv := range srvs {
+				seeds[i] = fmt.Sprintf("%s:%d", srv.Target, srv.Port)
+			}
+			return seeds, nil
+		}
+
+		if addrs, err := net.DefaultResolver.LookupHost(ctx, name); err == nil && len(addrs) > 0 {
+			seeds := make([]string, len(addrs))
+			for i, addr := range addrs {
+				seeds[i] = net.JoinHostPort(addr, strconv.Itoa(defaultPort))
+			}
+			return seeds, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveSeedsLocalhost(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	seeds, err := resolveSeeds(ctx, "localhost", 7946)
+	if err != nil {
+		t.Fatalf("resolveSeeds(localhost): %v", err)
+	}
+	if len(seeds) == 0 {
+		t.Fatalf("expected at least one seed address for localhost")
+	}
+	for _, seed := range seeds {
+		if !strings.HasSuffix(seed, ":7946") {
+			t.Errorf("seed %q missing expected port", seed)
+		}
+	}
+}
+
+// TestResolveSeedsBoundedByContext is a regression test for a bug where
+// resolveSeeds retried forever against a context with no deadline: a
+// name that never resolves (typo, DNS outage, container DNS not ready)
+// blocked start() forever with no error and no log line. It must give
+// up once ctx is done instead.
+func TestResolveSeedsBoundedByContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := resolveSeeds(ctx, "this-name-should-never-resolve.invalid.", 7946)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatalf("expected an error once the context deadline passed")
+	}
+	if elapsed > 5*time.Second {
+		t.Fatalf("resolveSeeds took %s to honor a 500ms deadline", elapsed)
+	}
+}
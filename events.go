@@ -0,0 +1,38 @@
+package main
+
+import "github.com/hashicorp/memberlist"
+
+// reportKind distinguishes why a server sent something down its updates
+// channel, so the reporter can tell "a value diverged" apart from "a
+// node joined or left" instead of treating every signal as a rescore.
+type reportKind int
+
+const (
+	reportValueChange reportKind = iota
+	reportNodeJoin
+	reportNodeLeave
+	reportNodeUpdate
+)
+
+// report is what a server sends on its updates channel: either a user
+// state change, or a membership event forwarded from config.Events.
+type report struct {
+	kind reportKind
+	node string
+}
+
+// eventLoop forwards memberlist membership events (join/leave/update)
+// into the same reporting channel used for user broadcasts, so the
+// reporter can react to cluster shape changes, not just color changes.
+func (s *server) eventLoop() {
+	for ev := range s.events {
+		switch ev.Event {
+		case memberlist.NodeJoin:
+			s.updates <- report{kind: reportNodeJoin, node: ev.Node.Name}
+		case memberlist.NodeLeave:
+			s.updates <- report{kind: reportNodeLeave, node: ev.Node.Name}
+		case memberlist.NodeUpdate:
+			s.updates <- report{kind: reportNodeUpdate, node: ev.Node.Name}
+		}
+	}
+}
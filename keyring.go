@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// primaryKeyStoreKey is the reserved store key under which the cluster's
+// desired primary encryption key is tracked, base64-encoded, as an
+// ordinary LWW entry. Routing key rotation through the same Lamport-
+// clocked store used for the demo's color value (store.go) means it
+// gets the same anti-entropy guarantees: a node that misses the gossip
+// broadcast of a rotation still converges on the next LocalState/
+// MergeRemoteState push/pull, instead of being stuck unable to decrypt
+// traffic from the rest of the cluster with no recovery path. We
+// deliberately never retire old keys from the local keyring for the
+// same reason: retiring one early, before every node has converged on
+// its replacement, would be the exact non-convergent failure mode this
+// is meant to avoid.
+const primaryKeyStoreKey = "__primary_key__"
+
+// generateSecretKey returns a fresh AES-256 key suitable for use as a
+// memberlist primary encryption key.
+func generateSecretKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// applyPrimaryKey installs key in the local keyring, if it isn't
+// already, and switches to it.
+func (s *server) applyPrimaryKey(key []byte) {
+	if err := s.keyring.AddKey(key); err != nil {
+		s.logger.Printf("install key: %v", err)
+	}
+	if err := s.keyring.UseKey(key); err != nil {
+		s.logger.Printf("use key: %v", err)
+	}
+}
+
+// RotateKey installs newKey as the cluster's primary encryption key. The
+// change is written to the store under primaryKeyStoreKey exactly like
+// a color mutation, so it propagates and converges the same way.
+func (s *server) RotateKey(newKey []byte) {
+	e := entry{
+		Value:  base64.StdEncoding.EncodeToString(newKey),
+		TS:     s.store.tick(),
+		Origin: s.name,
+	}
+	s.processStateChange(primaryKeyStoreKey, e)
+}
+
+// onStoreChange reacts to a winning store entry. Most keys are just
+// colors the reporter cares about, but primaryKeyStoreKey needs the
+// local keyring updated to match whenever it changes, whether that
+// change arrived as a live broadcast or an anti-entropy merge.
+func (s *server) onStoreChange(key string, e entry) {
+	if key != primaryKeyStoreKey {
+		return
+	}
+	raw, err := base64.StdEncoding.DecodeString(e.Value)
+	if err != nil {
+		s.logger.Printf("decode primary key: %v", err)
+		return
+	}
+	s.applyPrimaryKey(raw)
+}
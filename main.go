@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,6 +16,10 @@ import (
 
 const desiredServers = 20
 
+// colorKey is the single key this demo mutates; the store itself
+// supports arbitrary keys.
+const colorKey = "color"
+
 var colors = []string{
 	"red",
 	"orange",
@@ -26,10 +31,20 @@ var colors = []string{
 }
 
 func main() {
-	var updates = make(chan struct{})
+	var updates = make(chan report)
 	var servers = []*server{}
 	for i := 0; i < desiredServers; i++ {
-		s := newServer(updates)
+		cfg := Config{}
+		if i > 0 {
+			// Exercise the LAN profile and static-seed path: chain-join
+			// off the previous server instead of every node hammering
+			// the same localhost default.
+			cfg = Config{
+				Profile: ProfileLAN,
+				Seeds:   []string{"localhost:" + strconv.Itoa(defaultPort+i-1)},
+			}
+		}
+		s := newServer(updates, cfg)
 		go s.start()
 		servers = append(servers, s)
 	}
@@ -38,14 +53,27 @@ func main() {
 
 	go mutate(servers)
 
-	for range updates {
+	for r := range updates {
+		switch r.kind {
+		case reportNodeJoin:
+			logger.Printf("node joined: %s", r.node)
+			continue
+		case reportNodeLeave:
+			logger.Printf("node left: %s", r.node)
+			continue
+		case reportNodeUpdate:
+			logger.Printf("node updated: %s", r.node)
+			continue
+		}
+
 		counts := make(map[string]int)
-		var newest state
+		var newest entry
 		for _, s := range servers {
-			if s.state.TS.After(newest.TS) {
-				newest = s.state
+			e, _ := s.store.get(colorKey)
+			if e.after(newest) {
+				newest = e
 			}
-			counts[s.state.Value]++
+			counts[e.Value]++
 		}
 
 		score := 100 * (float32(counts[newest.Value]) / float32(len(servers)))
@@ -65,62 +93,87 @@ func mutate(servers []*server) {
 	}
 }
 
-type state struct {
-	TS    time.Time
-	Value string
-}
-
 type server struct {
-	updates chan<- struct{}
+	updates chan<- report
+	events  chan memberlist.NodeEvent
 	logger  *log.Logger
 	list    *memberlist.Memberlist
 	queue   *memberlist.TransmitLimitedQueue
-	state   state
+	keyring *memberlist.Keyring
+	store   *store
+	cfg     Config
 	name    string
 	port    int
 }
 
-func nextState() state {
-	return state{
-		TS:    time.Now(),
-		Value: colors[rand.Intn(len(colors))],
-	}
-}
-
 var numServers = 0
 
 const defaultPort = 7946
 
-func newServer(updates chan<- struct{}) *server {
+func newServer(updates chan<- report, cfg Config) *server {
 	name := fmt.Sprintf("[ s%d ] ", numServers)
+	st := newStore()
+	st.set(colorKey, entry{Value: colors[rand.Intn(len(colors))], TS: st.tick(), Origin: name})
 	s := &server{
 		updates: updates,
+		events:  make(chan memberlist.NodeEvent, 64),
 		logger:  log.New(io.Discard, name, log.Lshortfile|log.Ltime),
 		port:    defaultPort + numServers,
+		cfg:     cfg,
 		name:    name,
-		state:   nextState(),
+		store:   st,
 	}
 	numServers++
 	return s
 }
 
 func (s *server) mutate() {
-	s.processStateChange(nextState())
+	e := entry{Value: colors[rand.Intn(len(colors))], TS: s.store.tick(), Origin: s.name}
+	s.processStateChange(colorKey, e)
 }
 
 func (s *server) start() {
-	/* Create the initial memberlist from a safe configuration.
-		   Please reference the godoc for other default config types.
-	http://godoc.org/github.com/hashicorp/memberlist#Config
-	*/
-	config := memberlist.DefaultLocalConfig()
+	cs, err := loadClusterState(s.port)
+	if err != nil {
+		s.logger.Printf("failed to load cluster state, starting fresh: %v", err)
+	}
+
+	secretKey := []byte(nil)
+	if cs != nil && len(cs.Key) > 0 {
+		secretKey = cs.Key
+	} else if secretKey, err = generateSecretKey(); err != nil {
+		s.logger.Fatal("Failed to generate secret key: " + err.Error())
+	}
+
+	s.keyring, err = memberlist.NewKeyring(nil, secretKey)
+	if err != nil {
+		s.logger.Fatal("Failed to build keyring: " + err.Error())
+	}
+
+	// Start from whichever of memberlist's tuned profiles (local/LAN/WAN)
+	// the server was configured for, then layer our own overrides on
+	// top. Please reference the godoc for the full set of knobs each
+	// profile sets: http://godoc.org/github.com/hashicorp/memberlist#Config
+	config := s.cfg.Profile.memberlistConfig()
 	config.Name = s.name
+	if s.cfg.BindAddr != "" {
+		config.BindAddr = s.cfg.BindAddr
+	}
 	config.BindPort = s.port
 	config.AdvertisePort = s.port
-	config.Delegate = s
+	if s.cfg.AdvertisePort != 0 {
+		config.AdvertisePort = s.cfg.AdvertisePort
+	}
+	if s.cfg.Transport != nil {
+		config.Transport = s.cfg.Transport
+	}
+	config.Delegate = &gossipDelegate{s: s}
+	config.Events = &memberlist.ChannelEventDelegate{Ch: s.events}
+	config.Conflict = &conflictDelegate{s: s}
+	config.Alive = &aliveDelegate{s: s}
+	config.Keyring = s.keyring
 	config.LogOutput = io.Discard
 
-	var err error
 	s.list, err = memberlist.Create(config)
 	if err != nil {
 		s.logger.Fatal("Failed to create memberlist: " + err.Error())
@@ -133,94 +186,84 @@ func (s *server) start() {
 		RetransmitMult: 1,
 	}
 
-	// Join an existing cluster by specifying at least one known member.
-	_, err = s.list.Join([]string{"localhost:" + strconv.Itoa(defaultPort)})
+	go s.eventLoop()
+
+	// Join an existing cluster, preferring (in order) peers we remember
+	// from a previous run, statically configured seeds, and seeds
+	// discovered via DNS, falling back to the well-known localhost
+	// address if none of them are reachable.
+	fallback := "localhost:" + strconv.Itoa(defaultPort)
+	seeds := s.seedAddrs(cs)
+	if _, err = s.list.Join(seeds); err != nil && (len(seeds) == 0 || seeds[0] != fallback) {
+		s.logger.Printf("failed to join seeds %v, falling back to %s: %v", seeds, fallback, err)
+		_, err = s.list.Join([]string{fallback})
+	}
 	if err != nil {
 		s.logger.Fatal("Failed to join cluster: " + err.Error())
 	}
 
+	go s.persistPeriodically()
 }
 
-// NodeMeta is used to retrieve meta-data about the current node
-// when broadcasting an alive message. It's length is limited to
-// the given byte size. This metadata is available in the Node structure.
-func (s *server) NodeMeta(limit int) []byte {
-	return nil
-}
-
-// NotifyMsg is called when a user-data message is received.
-// Care should be taken that this method does not block, since doing
-// so would block the entire UDP packet receive loop. Additionally, the byte
-// slice may be modified after the call returns, so it should be copied if needed
-func (s *server) NotifyMsg(buf []byte) {
-	s.logger.Printf("NotifyMsg(%q)", buf)
-	var remoteState state
-	err := json.Unmarshal(buf, &remoteState)
-	if err != nil {
-		s.logger.Fatal(err)
+// seedAddrs picks the best available list of "host:port" peers to join
+// through: persisted cluster state first, then statically configured
+// seeds, then DNS discovery, in that order of preference.
+func (s *server) seedAddrs(cs *ClusterState) []string {
+	if cs != nil && len(cs.Peers) > 0 {
+		return cs.Peers
 	}
-	s.processStateChange(remoteState)
-}
-
-// GetBroadcasts is called when user data messages can be broadcast.
-// It can return a list of buffers to send. Each buffer should assume an
-// overhead as provided with a limit on the total byte size allowed.
-// The total byte size of the resulting data to send must not exceed
-// the limit. Care should be taken that this method does not block,
-// since doing so would block the entire UDP packet receive loop.
-func (s *server) GetBroadcasts(overhead, limit int) [][]byte {
-	return s.queue.GetBroadcasts(overhead, limit)
-}
-
-// LocalState is used for a TCP Push/Pull. This is sent to
-// the remote side in addition to the membership information. Any
-// data can be sent here. See MergeRemoteState as well. The `join`
-// boolean indicates this is for a join instead of a push/pull.
-func (s *server) LocalState(join bool) []byte {
-	s.logger.Printf("LocalState(%v)", join)
-	buf, _ := json.Marshal(s.state)
-	return buf
-}
-
-// MergeRemoteState is invoked after a TCP Push/Pull. This is the
-// state received from the remote side and is the result of the
-// remote side's LocalState call. The 'join'
-// boolean indicates this is for a join instead of a push/pull.
-func (s *server) MergeRemoteState(buf []byte, join bool) {
-	s.logger.Printf("MergeRemoteState(%q, %v)", buf, join)
-	var remoteState state
-	err := json.Unmarshal(buf, &remoteState)
-	if err != nil {
-		s.logger.Fatal(err)
+	if len(s.cfg.Seeds) > 0 {
+		return s.cfg.Seeds
+	}
+	if s.cfg.SeedDNSName != "" {
+		timeout := s.cfg.SeedDNSTimeout
+		if timeout <= 0 {
+			timeout = defaultSeedDNSTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		seeds, err := resolveSeeds(ctx, s.cfg.SeedDNSName, defaultPort)
+		cancel()
+		if err != nil {
+			s.logger.Printf("failed to resolve seed DNS name %q within %s: %v", s.cfg.SeedDNSName, timeout, err)
+		} else {
+			return seeds
+		}
 	}
-	s.processStateChange(remoteState)
+	return []string{"localhost:" + strconv.Itoa(defaultPort)}
 }
 
-func (s *server) processStateChange(newState state) {
-	if newState.TS.Before(s.state.TS) {
+// processStateChange applies e to key if it wins under LWW semantics,
+// notifying the reporter and re-broadcasting so the change continues to
+// propagate through the cluster.
+func (s *server) processStateChange(key string, e entry) {
+	if !s.store.set(key, e) {
 		return
 	}
 
-	s.state = newState
-	s.updates <- struct{}{}
-	s.queue.QueueBroadcast(StateChange{s.state})
+	s.onStoreChange(key, e)
+	s.updates <- report{kind: reportValueChange}
+	s.queue.QueueBroadcast(StateChange{Key: key, Entry: e})
 }
 
+// StateChange is a single key's LWW register, broadcast whenever it
+// changes so Invalidates can supersede a still-queued broadcast of the
+// same key with a newer one instead of sending both.
 type StateChange struct {
-	state
+	Key   string
+	Entry entry
 }
 
-func (s StateChange) Invalidates(b memberlist.Broadcast) bool {
+func (c StateChange) Invalidates(b memberlist.Broadcast) bool {
 	o, ok := b.(StateChange)
-	if !ok {
+	if !ok || o.Key != c.Key {
 		return false
 	}
-	return s.TS.After(o.TS)
+	return c.Entry.after(o.Entry)
 }
 
-func (s StateChange) Message() []byte {
-	buf, _ := json.Marshal(s.state)
-	return buf
+func (c StateChange) Message() []byte {
+	buf, _ := json.Marshal(c)
+	return append([]byte{byte(stateMsg)}, buf...)
 }
 
-func (s StateChange) Finished() {}
+func (c StateChange) Finished() {}
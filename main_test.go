@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func testServer(cfg Config) *server {
+	return &server{
+		logger: log.New(io.Discard, "", 0),
+		cfg:    cfg,
+	}
+}
+
+func TestSeedAddrsPrefersPersistedPeers(t *testing.T) {
+	s := testServer(Config{Seeds: []string{"static:1"}})
+	cs := &ClusterState{Peers: []string{"persisted:1", "persisted:2"}}
+
+	got := s.seedAddrs(cs)
+	if len(got) != 2 || got[0] != "persisted:1" {
+		t.Fatalf("expected persisted peers to take priority, got %v", got)
+	}
+}
+
+func TestSeedAddrsFallsBackToStaticSeeds(t *testing.T) {
+	s := testServer(Config{Seeds: []string{"static:1", "static:2"}})
+
+	got := s.seedAddrs(nil)
+	if len(got) != 2 || got[0] != "static:1" {
+		t.Fatalf("expected static seeds, got %v", got)
+	}
+}
+
+// TestSeedAddrsFallsBackToLocalhostAfterDNSTimeout exercises the full
+// DNS-discovery path end to end: with no persisted peers or static
+// seeds, a SeedDNSName that can't resolve must time out (bounded by
+// SeedDNSTimeout) and fall back to the localhost default rather than
+// hanging start() forever.
+func TestSeedAddrsFallsBackToLocalhostAfterDNSTimeout(t *testing.T) {
+	s := testServer(Config{
+		SeedDNSName:    "this-name-should-never-resolve.invalid.",
+		SeedDNSTimeout: 300 * time.Millisecond,
+	})
+
+	start := time.Now()
+	got := s.seedAddrs(nil)
+	elapsed := time.Since(start)
+
+	want := "localhost:7946"
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("expected fallback seed %q, got %v", want, got)
+	}
+	if elapsed > 5*time.Second {
+		t.Fatalf("seedAddrs took %s to fall back after a 300ms DNS timeout", elapsed)
+	}
+}
@@ -0,0 +1,12 @@
+package main
+
+// messageType tags the first byte of every gossip broadcast so NotifyMsg
+// can tell message kinds apart without guessing at the JSON payload.
+// There's only one kind today (a store key/entry change, including key
+// rotation — see keyring.go), but the tag keeps room for more without
+// another breaking wire-format change.
+type messageType uint8
+
+const (
+	stateMsg messageType = iota
+)
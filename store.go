@@ -0,0 +1,98 @@
+package main
+
+import "sync"
+
+// Lamport is a logical clock used to order writes to the same key even
+// when two mutations land in the same wall-clock nanosecond.
+type Lamport uint64
+
+// entry is a single last-writer-wins register: a value, the logical
+// clock it was written at, and which node wrote it. Ties on TS are
+// broken by Origin so every node agrees on a winner without needing
+// synchronized clocks.
+type entry struct {
+	Value  string
+	TS     Lamport
+	Origin string
+}
+
+// after reports whether e should win over o under LWW semantics.
+func (e entry) after(o entry) bool {
+	if e.TS != o.TS {
+		return e.TS > o.TS
+	}
+	return e.Origin > o.Origin
+}
+
+// store is a small multi-key CRDT: a map of independently-converging LWW
+// registers plus the Lamport clock used to stamp local writes. Merging
+// two stores (in any order, any number of times) converges to the same
+// map, which is what makes TCP push/pull a true anti-entropy sweep
+// instead of a last-writer-wins coin flip on wall-clock time.
+type store struct {
+	mu      sync.Mutex
+	clock   Lamport
+	entries map[string]entry
+}
+
+func newStore() *store {
+	return &store{entries: make(map[string]entry)}
+}
+
+// tick advances the local Lamport clock and returns the new value, for
+// stamping a locally-originated write.
+func (st *store) tick() Lamport {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.clock++
+	return st.clock
+}
+
+// set applies e to key if it wins over whatever is currently stored,
+// raising the clock to at least e.TS so the next tick, and so any later
+// local write, sorts after anything seen from the network. It reports whether the store
+// changed.
+func (st *store) set(key string, e entry) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if e.TS > st.clock {
+		st.clock = e.TS
+	}
+	if current, ok := st.entries[key]; ok && !e.after(current) {
+		return false
+	}
+	st.entries[key] = e
+	return true
+}
+
+// get returns the current entry for key, if any.
+func (st *store) get(key string) (entry, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	e, ok := st.entries[key]
+	return e, ok
+}
+
+// snapshot returns a copy of the full key/entry map, for anti-entropy
+// exchange over TCP push/pull.
+func (st *store) snapshot() map[string]entry {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	out := make(map[string]entry, len(st.entries))
+	for k, v := range st.entries {
+		out[k] = v
+	}
+	return out
+}
+
+// merge applies every entry in remote, keeping whichever side wins per
+// key, and returns the keys that actually changed as a result.
+func (st *store) merge(remote map[string]entry) []string {
+	var changed []string
+	for key, e := range remote {
+		if st.set(key, e) {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+func TestEntryAfterTiesBrokenByOrigin(t *testing.T) {
+	a := entry{Value: "a", TS: 5, Origin: "alpha"}
+	b := entry{Value: "b", TS: 5, Origin: "beta"}
+
+	if a.after(b) {
+		t.Errorf("alpha should not win a TS tie against beta")
+	}
+	if !b.after(a) {
+		t.Errorf("beta should win a TS tie against alpha")
+	}
+}
+
+// TestStoreSetConcurrentSameKeyWrites simulates two origins racing to
+// write the same key at the same Lamport time (the "same wall-clock
+// nanosecond" scenario the CRDT rewrite was meant to fix): the tie is
+// decided by Origin, not by which write is applied first, so the
+// higher-origin write wins even when applied second, and every replica
+// that sees both writes (in either order) must converge on the same
+// winner.
+func TestStoreSetConcurrentSameKeyWrites(t *testing.T) {
+	a := entry{Value: "red", TS: 1, Origin: "s1"}
+	b := entry{Value: "blue", TS: 1, Origin: "s2"}
+
+	applyAB := newStore()
+	applyAB.set(colorKey, a)
+	changed := applyAB.set(colorKey, b)
+	if !changed {
+		t.Fatalf("b should have won the tie and changed the store")
+	}
+
+	applyBA := newStore()
+	applyBA.set(colorKey, b)
+	applyBA.set(colorKey, a)
+
+	got, _ := applyAB.get(colorKey)
+	want, _ := applyBA.get(colorKey)
+	if got != want {
+		t.Fatalf("replicas diverged: applied A,B got %+v; applied B,A got %+v", got, want)
+	}
+	if got.Value != "blue" {
+		t.Fatalf("expected blue (higher origin) to win, got %q", got.Value)
+	}
+}
+
+func TestStoreMergeAfterMissedUpdate(t *testing.T) {
+	a := newStore()
+	a.set(colorKey, entry{Value: "red", TS: 1, Origin: "s1"})
+	a.set(colorKey, entry{Value: "green", TS: 2, Origin: "s1"})
+
+	// b missed both of a's broadcasts entirely and is still on its own
+	// older value.
+	b := newStore()
+	b.set(colorKey, entry{Value: "yellow", TS: 1, Origin: "s2"})
+
+	changed := b.merge(a.snapshot())
+	if len(changed) != 1 || changed[0] != colorKey {
+		t.Fatalf("expected merge to report %q changed, got %v", colorKey, changed)
+	}
+
+	got, ok := b.get(colorKey)
+	if !ok || got.Value != "green" {
+		t.Fatalf("expected anti-entropy merge to converge on green, got %+v", got)
+	}
+}
+
+func TestStoreMergeIsNoopWhenRemoteIsStale(t *testing.T) {
+	a := newStore()
+	a.set(colorKey, entry{Value: "green", TS: 5, Origin: "s1"})
+
+	stale := map[string]entry{colorKey: {Value: "red", TS: 1, Origin: "s1"}}
+	if changed := a.merge(stale); len(changed) != 0 {
+		t.Fatalf("merging a stale entry should not report a change, got %v", changed)
+	}
+
+	got, _ := a.get(colorKey)
+	if got.Value != "green" {
+		t.Fatalf("stale merge must not overwrite a newer local value, got %q", got.Value)
+	}
+}
+
+// TestStoreClockMonotonic checks that tick always advances, and that
+// set() raises the clock to a remote TS so the next tick, and so a
+// subsequent local write, sorts after anything learned from the network.
+func TestStoreClockMonotonic(t *testing.T) {
+	st := newStore()
+	first := st.tick()
+	second := st.tick()
+	if second <= first {
+		t.Fatalf("tick must be strictly increasing, got %d then %d", first, second)
+	}
+
+	st.set(colorKey, entry{Value: "remote", TS: first + 100, Origin: "other"})
+	next := st.tick()
+	if next <= first+100 {
+		t.Fatalf("tick after seeing a remote TS of %d should exceed it, got %d", first+100, next)
+	}
+}